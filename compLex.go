@@ -3,11 +3,13 @@ package main
 import (
 	"fmt"
 	"unicode"
+	"unicode/utf8"
 )
 
 type (
-	Acceptor func(rune) bool
-	state    func(rune) (bool, state) // bool in return expr must eval before state
+	Acceptor    func(rune) bool
+	ErrAcceptor func(rune) (bool, error)
+	state       func(rune) (bool, state) // bool in return expr must eval before state
 )
 
 func Acc(spec interface{}) (acc Acceptor) {
@@ -69,120 +71,277 @@ func statify(acc Acceptor) state {
 	return internal
 }
 
+/********** Errors **********/
+
+// AcceptorError is returned by an ErrAcceptor when an assertion fails.
+// RuneIndex and ByteOffset count from the first rune the assertion itself
+// was fed, so they match positions in the caller's input only when the
+// assertion sees that input from its start (not, e.g., as a later link
+// of a Chain).
+type AcceptorError struct {
+	Rune       rune
+	RuneIndex  int
+	ByteOffset int
+	Err        error
+}
+
+func (e *AcceptorError) Error() string {
+	return fmt.Sprintf("rune %q at index %d (byte %d): %v", e.Rune, e.RuneIndex, e.ByteOffset, e.Err)
+}
+
+func (e *AcceptorError) Unwrap() error { return e.Err }
+
+// Lift adapts a plain Acceptor into an ErrAcceptor that never errors, so
+// it can be composed with assertions inside Chain, FirstOf, and friends.
+func Lift(acc Acceptor) ErrAcceptor {
+	return func(rn rune) (bool, error) {
+		return acc(rn), nil
+	}
+}
+
+// Must adapts an ErrAcceptor back into a plain Acceptor that panics with
+// the underlying error instead of returning it, for callers who prefer
+// the old panic-based control flow.
+func Must(acc ErrAcceptor) Acceptor {
+	return func(rn rune) bool {
+		ok, err := acc(rn)
+		if err != nil {
+			panic(err)
+		}
+		return ok
+	}
+}
+
+// Run feeds src through acc one rune at a time. It returns the prefix
+// accepted before the first rejection as consumed, everything from there
+// on as remaining, and a non-nil err if acc errored. On an error, consumed
+// also includes the rune that errored and remaining starts after it.
+func Run(acc ErrAcceptor, src string) (consumed, remaining string, err error) {
+	for i, rn := range src {
+		ok, rerr := acc(rn)
+		if rerr != nil {
+			end := i + utf8.RuneLen(rn)
+			return src[:end], src[end:], rerr
+		}
+		if !ok {
+			return src[:i], src[i:], nil
+		}
+	}
+	return src, "", nil
+}
+
+/********** Resetter **********/
+
+// Resetter is implemented by combinators that carry progress between
+// calls, letting a caller -- chiefly Scanner -- rewind one back to its
+// initial state for reuse instead of reconstructing it from scratch.
+// Composition functions take their children as Composable so Reset()
+// recurses down the whole acceptor tree, not just the outermost
+// combinator's own bookkeeping.
+type Resetter interface {
+	Reset()
+}
+
+type resetFunc func()
+
+func (r resetFunc) Reset() { r() }
+
+// NoReset is the Resetter for acceptors with no internal state to rewind.
+var NoReset Resetter = resetFunc(func() {})
+
+// Composable pairs an ErrAcceptor with the Resetter that rewinds it, so a
+// composition function can reset every acceptor it wraps, not just its
+// own bookkeeping.
+type Composable struct {
+	Acc   ErrAcceptor
+	Reset Resetter
+}
+
 /********** Acceptor Composition Functions **********/
 
-func Branch(mapping map[rune]Acceptor, alt Acceptor) Acceptor {
-	var internal state = func(rn rune) (bool, state) {
+func Branch(mapping map[rune]Composable, alt Composable) (ErrAcceptor, Resetter) {
+	var chosen *Composable
+	fn := func(rn rune) (bool, error) {
+		if chosen != nil {
+			return chosen.Acc(rn)
+		}
 		if next, ok := mapping[rn]; ok {
-			return true, statify(next)
+			chosen = &next
+			return true, nil
 		}
-		return alt(rn), statify(alt)
-	}
-	return func(rn rune) (out bool) {
-		out, internal = internal(rn)
-		return
+		chosen = &alt
+		return alt.Acc(rn)
 	}
+	reset := resetFunc(func() {
+		chosen = nil
+		for _, c := range mapping {
+			c.Reset.Reset()
+		}
+		alt.Reset.Reset()
+	})
+	return fn, reset
 }
 
-func Skip(skip int, acc Acceptor) Acceptor {
-	return Chain(Truncate(skip, All()), acc)
+func Skip(skip int, acc Composable) (ErrAcceptor, Resetter) {
+	trunc, truncReset := Truncate(skip, Composable{Acc: Lift(All()), Reset: NoReset})
+	return Chain(Composable{Acc: trunc, Reset: truncReset}, acc)
 }
 
-func FirstOf(accs ...Acceptor) Acceptor {
-	var internal state = func(rn rune) (bool, state) {
-		for _, acc := range accs {
-			if acc(rn) {
-				return true, statify(acc)
+func FirstOf(accs ...Composable) (ErrAcceptor, Resetter) {
+	var chosen *Composable
+	fn := func(rn rune) (bool, error) {
+		if chosen != nil {
+			return chosen.Acc(rn)
+		}
+		for i := range accs {
+			ok, err := accs[i].Acc(rn)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				chosen = &accs[i]
+				return true, nil
 			}
 		}
 		return false, nil
 	}
-	return func(rn rune) (out bool) {
-		out, internal = internal(rn)
-		return out
-	}
+	reset := resetFunc(func() {
+		chosen = nil
+		for _, a := range accs {
+			a.Reset.Reset()
+		}
+	})
+	return fn, reset
 }
 
-func Truncate(max int, acc Acceptor) Acceptor {
+func Truncate(max int, acc Composable) (ErrAcceptor, Resetter) {
 	count := 0
-	return func(rn rune) bool {
-		if count < max && acc(rn) {
-			count++
-			return true
+	fn := func(rn rune) (bool, error) {
+		if count < max {
+			ok, err := acc.Acc(rn)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				count++
+				return true, nil
+			}
 		}
-		return false
+		return false, nil
 	}
+	reset := resetFunc(func() {
+		count = 0
+		acc.Reset.Reset()
+	})
+	return fn, reset
 }
 
-func EndsBefore(delim rune, acc Acceptor) Acceptor {
-	return func(rn rune) bool {
+func EndsBefore(delim rune, acc Composable) (ErrAcceptor, Resetter) {
+	fn := func(rn rune) (bool, error) {
 		if rn == delim {
-			return false
+			return false, nil
 		}
-		return acc(rn)
+		return acc.Acc(rn)
 	}
+	return fn, acc.Reset
 }
 
-func EndsWith(delim rune, acc Acceptor) Acceptor {
+func EndsWith(delim rune, acc Composable) (ErrAcceptor, Resetter) {
 	seen := false
-	return func(rn rune) bool {
+	fn := func(rn rune) (bool, error) {
 		if seen {
-			return false
+			return false, nil
 		}
 		if rn == delim {
 			seen = true
-			return true
+			return true, nil
 		}
-		return acc(rn)
+		return acc.Acc(rn)
 	}
+	reset := resetFunc(func() {
+		seen = false
+		acc.Reset.Reset()
+	})
+	return fn, reset
 }
 
-func Chain(accs ...Acceptor) Acceptor {
+func Chain(accs ...Composable) (ErrAcceptor, Resetter) {
 	i, n := 0, len(accs)
-	return func(rn rune) bool {
+	fn := func(rn rune) (bool, error) {
 		for ; i != n; i++ {
-			if accs[i](rn) {
-				return true
+			ok, err := accs[i].Acc(rn)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
 			}
 		}
-		return false
+		return false, nil
 	}
+	reset := resetFunc(func() {
+		i = 0
+		for _, a := range accs {
+			a.Reset.Reset()
+		}
+	})
+	return fn, reset
 }
 
 /********** Acceptor Assertions **********/
 
-func AssertStart(acc Acceptor, err error) Acceptor {
-	var internal state = func(rn rune) (bool, state) {
-		if !acc(rn) {
-			panic(err)
+// AssertStart requires that the first rune satisfy acc, surfacing an
+// *AcceptorError wrapping err if it does not; every rune after the first
+// is passed through to acc unchecked.
+func AssertStart(acc Acceptor, err error) (ErrAcceptor, Resetter) {
+	started := false
+	index, offset := 0, 0
+	fn := func(rn rune) (bool, error) {
+		ok := acc(rn)
+		var rerr error
+		if !started {
+			started = true
+			if !ok {
+				rerr = &AcceptorError{Rune: rn, RuneIndex: index, ByteOffset: offset, Err: err}
+			} else {
+				ok = true
+			}
 		}
-		return true, statify(acc)
-	}
-	return func(rn rune) (out bool) {
-		out, internal = internal(rn)
-		return
+		index++
+		offset += utf8.RuneLen(rn)
+		return ok, rerr
 	}
+	return fn, resetFunc(func() { started = false; index, offset = 0, 0 })
 }
 
-func AssertAtMost(acc Acceptor, most int, err error) Acceptor {
+// AssertAtMost requires that acc accept no more than most runes,
+// surfacing an *AcceptorError wrapping err on the rune that would exceed
+// it instead of panicking.
+func AssertAtMost(acc Acceptor, most int, err error) (ErrAcceptor, Resetter) {
 	count := 0
-	return func(rn rune) bool {
+	index, offset := 0, 0
+	fn := func(rn rune) (bool, error) {
+		defer func() {
+			index++
+			offset += utf8.RuneLen(rn)
+		}()
 		if acc(rn) {
 			if count == most {
-				panic(err)
+				return false, &AcceptorError{Rune: rn, RuneIndex: index, ByteOffset: offset, Err: err}
 			}
 			count++
-			return true
+			return true, nil
 		}
-		return false
+		return false, nil
 	}
+	return fn, resetFunc(func() { count = 0; index, offset = 0, 0 })
 }
 
 /********** Demo **********/
 
 func main() {
-	acceptor    :=  EndsBefore('m', All())
-	acceptorSrc := `EndsBefore('m', All())`
+	acceptor, _ := EndsBefore('m', Composable{Acc: Lift(All()), Reset: NoReset})
+	acceptorSrc := `EndsBefore('m', Composable{Acc: Lift(All()), Reset: NoReset})`
 
 	src := "This is a demo."
 
@@ -191,7 +350,8 @@ func main() {
 	fmt.Printf("Source:   \"%s\"\n\n", src)
 
 	for i, rn := range src {
-		if !acceptor(rn) {
+		ok, err := acceptor(rn)
+		if err != nil || !ok {
 			fmt.Printf("--------\n")
 			fmt.Printf("Rejected '%c' at %d ...\n\n", rn, i)
 			fmt.Printf("Consumed:  \"%s\"\n\n", src[:i+1])
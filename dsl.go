@@ -0,0 +1,397 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+)
+
+/********** Expression DSL **********/
+
+// unicodeCategories maps the `{Name}` category spellings recognized by
+// Compile to their unicode.RangeTable.
+var unicodeCategories = map[string]*unicode.RangeTable{
+	"Letter":  unicode.Letter,
+	"Digit":   unicode.Digit,
+	"Number":  unicode.Number,
+	"Space":   unicode.White_Space,
+	"Punct":   unicode.Punct,
+	"Symbol":  unicode.Symbol,
+	"Upper":   unicode.Upper,
+	"Lower":   unicode.Lower,
+	"Mark":    unicode.Mark,
+	"Control": unicode.Cc,
+}
+
+// ParseError reports a Compile failure at a specific line and column of
+// the source expression.
+type ParseError struct {
+	Line, Col int
+	Msg       string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+// Compile parses expr, a small infix DSL built from the existing
+// combinators, into an Acceptor. The grammar:
+//
+//	expr    = alt [ "..." ( "until" | "through" ) rune ]
+//	alt     = concat { "|" concat }
+//	concat  = factor { factor }
+//	factor  = "!" factor | "<=" number factor | primitive [ "{" number "," number "}" ]
+//	primitive = rune | "[" class "]" | "\" escape | "{" category "}"
+//
+// Literal runes are written 'a', classes as [a-z] or [!a-z], escapes as
+// \d or \s, and unicode categories as {Letter}. The repetition bound
+// {lo,hi} only supports a lo of 0, since Truncate has no way to enforce a
+// minimum; any other lo is a *ParseError. Compile reports positional
+// errors via *ParseError; a successfully compiled Acceptor behaves
+// identically to the equivalent hand-written composition.
+func Compile(expr string) (Acceptor, error) {
+	p := &dslParser{src: []rune(expr), line: 1, col: 1}
+	acc, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		return nil, p.errorf("unexpected trailing input")
+	}
+	return acc, nil
+}
+
+type dslParser struct {
+	src       []rune
+	pos       int
+	line, col int
+}
+
+func (p *dslParser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Line: p.line, Col: p.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *dslParser) peek() rune {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *dslParser) advance() rune {
+	rn := p.src[p.pos]
+	p.pos++
+	if rn == '\n' {
+		p.line++
+		p.col = 1
+	} else {
+		p.col++
+	}
+	return rn
+}
+
+func (p *dslParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.advance()
+	}
+}
+
+func (p *dslParser) hasPrefix(s string) bool {
+	runes := []rune(s)
+	if p.pos+len(runes) > len(p.src) {
+		return false
+	}
+	for i, rn := range runes {
+		if p.src[p.pos+i] != rn {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *dslParser) consumeLiteral(s string) bool {
+	if !p.hasPrefix(s) {
+		return false
+	}
+	for range []rune(s) {
+		p.advance()
+	}
+	return true
+}
+
+func (p *dslParser) consumeRune(rn rune) bool {
+	if p.peek() != rn {
+		return false
+	}
+	p.advance()
+	return true
+}
+
+func (p *dslParser) parseExpr() (Acceptor, error) {
+	acc, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.consumeLiteral("...") {
+		return acc, nil
+	}
+	p.skipSpace()
+	switch {
+	case p.consumeLiteral("until"):
+		p.skipSpace()
+		delim, err := p.parseRuneLit()
+		if err != nil {
+			return nil, err
+		}
+		wrapped, _ := EndsBefore(delim, Composable{Acc: Lift(acc), Reset: NoReset})
+		acc = Must(wrapped)
+	case p.consumeLiteral("through"):
+		p.skipSpace()
+		delim, err := p.parseRuneLit()
+		if err != nil {
+			return nil, err
+		}
+		wrapped, _ := EndsWith(delim, Composable{Acc: Lift(acc), Reset: NoReset})
+		acc = Must(wrapped)
+	default:
+		return nil, p.errorf("expected 'until' or 'through' after '...'")
+	}
+	return acc, nil
+}
+
+func (p *dslParser) parseAlt() (Acceptor, error) {
+	first, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	accs := []Acceptor{first}
+	for {
+		save, saveLine, saveCol := p.pos, p.line, p.col
+		p.skipSpace()
+		if !p.consumeRune('|') {
+			p.pos, p.line, p.col = save, saveLine, saveCol
+			break
+		}
+		p.skipSpace()
+		next, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		accs = append(accs, next)
+	}
+	if len(accs) == 1 {
+		return accs[0], nil
+	}
+	lifted := make([]Composable, len(accs))
+	for i, a := range accs {
+		lifted[i] = Composable{Acc: Lift(a), Reset: NoReset}
+	}
+	acc, _ := FirstOf(lifted...)
+	return Must(acc), nil
+}
+
+func (p *dslParser) parseConcat() (Acceptor, error) {
+	var accs []Acceptor
+	for {
+		p.skipSpace()
+		if p.atTerm() {
+			break
+		}
+		acc, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		accs = append(accs, acc)
+	}
+	if len(accs) == 0 {
+		return nil, p.errorf("expected expression")
+	}
+	if len(accs) == 1 {
+		return accs[0], nil
+	}
+	lifted := make([]Composable, len(accs))
+	for i, a := range accs {
+		lifted[i] = Composable{Acc: Lift(a), Reset: NoReset}
+	}
+	acc, _ := Chain(lifted...)
+	return Must(acc), nil
+}
+
+func (p *dslParser) atTerm() bool {
+	return p.pos >= len(p.src) || p.peek() == '|' || p.hasPrefix("...")
+}
+
+func (p *dslParser) parseFactor() (Acceptor, error) {
+	if p.consumeRune('!') {
+		p.skipSpace()
+		inner, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		acc, _ := AssertStart(inner, fmt.Errorf("assertion failed at start"))
+		return Must(acc), nil
+	}
+	if p.consumeLiteral("<=") {
+		n, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		inner, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		acc, _ := AssertAtMost(inner, n, fmt.Errorf("exceeded maximum of %d", n))
+		return Must(acc), nil
+	}
+
+	acc, err := p.parsePrimitive()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() == '{' {
+		lo, hi, err := p.parseRepetition()
+		if err != nil {
+			return nil, err
+		}
+		if lo != 0 {
+			return nil, p.errorf("minimum repetition counts are not supported, only {0,%d}", hi)
+		}
+		truncated, _ := Truncate(hi, Composable{Acc: Lift(acc), Reset: NoReset})
+		acc = Must(truncated)
+	}
+	return acc, nil
+}
+
+func (p *dslParser) parsePrimitive() (Acceptor, error) {
+	switch p.peek() {
+	case '\'':
+		rn, err := p.parseRuneLit()
+		if err != nil {
+			return nil, err
+		}
+		return Acc(rn), nil
+	case '[':
+		return p.parseClass()
+	case '\\':
+		return p.parseEscape()
+	case '{':
+		return p.parseCategory()
+	default:
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unexpected end of expression")
+		}
+		return nil, p.errorf("unexpected character %q", p.peek())
+	}
+}
+
+func (p *dslParser) parseRuneLit() (rune, error) {
+	if !p.consumeRune('\'') {
+		return 0, p.errorf("expected rune literal")
+	}
+	if p.pos >= len(p.src) {
+		return 0, p.errorf("unterminated rune literal")
+	}
+	rn := p.advance()
+	if !p.consumeRune('\'') {
+		return 0, p.errorf("unterminated rune literal")
+	}
+	return rn, nil
+}
+
+func (p *dslParser) parseClass() (Acceptor, error) {
+	p.advance() // '['
+	negate := false
+	if p.peek() == '!' {
+		negate = true
+		p.advance()
+	}
+	var class []rune
+	for p.pos < len(p.src) && p.peek() != ']' {
+		lo := p.advance()
+		if p.peek() == '-' && p.pos+1 < len(p.src) && p.src[p.pos+1] != ']' {
+			p.advance()
+			hi := p.advance()
+			for rn := lo; rn <= hi; rn++ {
+				class = append(class, rn)
+			}
+		} else {
+			class = append(class, lo)
+		}
+	}
+	if !p.consumeRune(']') {
+		return nil, p.errorf("unterminated character class")
+	}
+	set := Acc(class)
+	if negate {
+		return func(rn rune) bool { return !set(rn) }, nil
+	}
+	return set, nil
+}
+
+func (p *dslParser) parseEscape() (Acceptor, error) {
+	p.advance() // '\'
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unterminated escape")
+	}
+	switch rn := p.advance(); rn {
+	case 'd':
+		return Acc(unicode.Digit), nil
+	case 's':
+		return Acc(unicode.White_Space), nil
+	default:
+		return Acc(rn), nil
+	}
+}
+
+func (p *dslParser) parseCategory() (Acceptor, error) {
+	p.advance() // '{'
+	start := p.pos
+	for p.pos < len(p.src) && p.peek() != '}' {
+		p.advance()
+	}
+	name := string(p.src[start:p.pos])
+	if !p.consumeRune('}') {
+		return nil, p.errorf("unterminated category")
+	}
+	table, ok := unicodeCategories[name]
+	if !ok {
+		return nil, p.errorf("unknown unicode category %q", name)
+	}
+	return Acc(table), nil
+}
+
+func (p *dslParser) parseRepetition() (lo, hi int, err error) {
+	p.advance() // '{'
+	lo, err = p.parseNumber()
+	if err != nil {
+		return 0, 0, err
+	}
+	if !p.consumeRune(',') {
+		return 0, 0, p.errorf("expected ',' in repetition")
+	}
+	hi, err = p.parseNumber()
+	if err != nil {
+		return 0, 0, err
+	}
+	if !p.consumeRune('}') {
+		return 0, 0, p.errorf("unterminated repetition")
+	}
+	return lo, hi, nil
+}
+
+func (p *dslParser) parseNumber() (int, error) {
+	start := p.pos
+	for p.pos < len(p.src) && p.peek() >= '0' && p.peek() <= '9' {
+		p.advance()
+	}
+	if p.pos == start {
+		return 0, p.errorf("expected number")
+	}
+	n := 0
+	for _, rn := range p.src[start:p.pos] {
+		n = n*10 + int(rn-'0')
+	}
+	return n, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+/********** Glob Patterns **********/
+
+// globAtom is one compiled unit of a glob pattern: either a single-rune
+// matcher or a `*` wildcard, which may match zero or more runes.
+type globAtom struct {
+	star  bool
+	match Acceptor
+}
+
+// compileGlob turns a glob pattern into a sequence of globAtoms, expanding
+// `[...]` classes and `\`-escapes along the way. `?` becomes a match-any
+// atom and `*` becomes a star atom; everything else is a literal rune.
+func compileGlob(pattern string) []globAtom {
+	runes := []rune(pattern)
+	atoms := make([]globAtom, 0, len(runes))
+
+	for i := 0; i < len(runes); i++ {
+		switch rn := runes[i]; rn {
+		case '\\':
+			i++
+			if i < len(runes) {
+				atoms = append(atoms, globAtom{match: Acc(runes[i])})
+			}
+
+		case '*':
+			atoms = append(atoms, globAtom{star: true})
+
+		case '?':
+			atoms = append(atoms, globAtom{match: All()})
+
+		case '[':
+			class, negate, next := parseGlobClass(runes, i+1)
+			set := Acc(class)
+			if negate {
+				atoms = append(atoms, globAtom{match: func(rn rune) bool { return !set(rn) }})
+			} else {
+				atoms = append(atoms, globAtom{match: set})
+			}
+			i = next
+
+		default:
+			atoms = append(atoms, globAtom{match: Acc(rn)})
+		}
+	}
+
+	return atoms
+}
+
+// parseGlobClass reads a `[...]` character class starting just after the
+// `[`, expanding `a-z` ranges, and returns the matched runes, whether the
+// class is negated with a leading `!`, and the index of the closing `]`.
+func parseGlobClass(runes []rune, start int) (class []rune, negate bool, end int) {
+	i := start
+	if i < len(runes) && runes[i] == '!' {
+		negate = true
+		i++
+	}
+	for i < len(runes) && runes[i] != ']' {
+		if i+2 < len(runes) && runes[i+1] == '-' && runes[i+2] != ']' {
+			for rn := runes[i]; rn <= runes[i+2]; rn++ {
+				class = append(class, rn)
+			}
+			i += 3
+		} else {
+			class = append(class, runes[i])
+			i++
+		}
+	}
+	return class, negate, i
+}
+
+// globClosure expands a set of atom positions with every position reachable
+// by matching zero runes, i.e. by stepping over any run of `*` atoms.
+func globClosure(atoms []globAtom, positions map[int]bool) map[int]bool {
+	closure := make(map[int]bool, len(positions))
+	var visit func(int)
+	visit = func(p int) {
+		if closure[p] {
+			return
+		}
+		closure[p] = true
+		if p < len(atoms) && atoms[p].star {
+			visit(p + 1)
+		}
+	}
+	for p := range positions {
+		visit(p)
+	}
+	return closure
+}
+
+// globStep advances a set of atom positions by one rune, returning the
+// epsilon-closed set of positions still feasible afterward.
+func globStep(atoms []globAtom, positions map[int]bool, rn rune) map[int]bool {
+	next := make(map[int]bool, len(positions))
+	for p := range positions {
+		switch {
+		case p == len(atoms):
+			continue
+		case atoms[p].star:
+			next[p] = true
+		case atoms[p].match(rn):
+			next[p+1] = true
+		}
+	}
+	return globClosure(atoms, next)
+}
+
+// Glob compiles a glob pattern -- supporting `*`, `?`, `[abc]`/`[a-z]`
+// classes and `\`-escapes -- into a stateful Acceptor. It accepts runes as
+// long as the consumed prefix remains a possible prefix of some string
+// matching the pattern, and rejects the first rune that makes every
+// continuation infeasible. Internally it compiles the pattern to an NFA
+// over runes and drives it by stepping an epsilon-closed set of atom
+// positions (see globStep/globClosure) one rune at a time.
+//
+// This deliberately doesn't go through the `state` type: state models a
+// single self-transitioning acceptor with no transition table of its own
+// (statify just wraps an Acceptor in a state that always loops back to
+// itself), so it has no way to hold a set of concurrently live positions.
+// Driving the glob NFA through it would mean rebuilding position-set
+// stepping on top of state anyway, just with an extra layer of
+// indirection.
+func Glob(pattern string) Acceptor {
+	atoms := compileGlob(pattern)
+	positions := globClosure(atoms, map[int]bool{0: true})
+	return func(rn rune) bool {
+		positions = globStep(atoms, positions, rn)
+		return len(positions) > 0
+	}
+}
+
+// GlobMatch reports whether src, in its entirety, matches pattern.
+func GlobMatch(pattern, src string) bool {
+	atoms := compileGlob(pattern)
+	positions := globClosure(atoms, map[int]bool{0: true})
+	for _, rn := range src {
+		positions = globStep(atoms, positions, rn)
+		if len(positions) == 0 {
+			return false
+		}
+	}
+	return positions[len(atoms)]
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+/********** Scanner **********/
+
+// InvalidUTF8Policy controls how Scanner handles bytes that do not decode
+// as valid UTF-8.
+type InvalidUTF8Policy int
+
+const (
+	// SkipInvalidUTF8 drops the offending byte and keeps reading.
+	SkipInvalidUTF8 InvalidUTF8Policy = iota
+	// ReplaceInvalidUTF8 substitutes U+FFFD for the offending byte.
+	ReplaceInvalidUTF8
+	// ErrorOnInvalidUTF8 fails Next with an error.
+	ErrorOnInvalidUTF8
+)
+
+// NamedAcceptor binds a rule name to an ErrAcceptor and the Resetter that
+// rewinds it, so Scanner can cycle through rules and reuse each one for
+// the next token instead of rebuilding it.
+type NamedAcceptor struct {
+	Name  string
+	Acc   ErrAcceptor
+	Reset Resetter
+}
+
+// Position locates a rune within a scanned stream.
+type Position struct {
+	Line, Col int
+	Offset    int // byte offset
+}
+
+// Token is one lexeme recognized by Scanner: the rule that matched it, the
+// half-open range of Positions it spans, and its bytes. Bytes is the UTF-8
+// encoding of the decoded runes, so a byte replaced under
+// ReplaceInvalidUTF8 appears as U+FFFD and a byte dropped under
+// SkipInvalidUTF8 is absent.
+type Token struct {
+	Name  string
+	Start Position
+	End   Position
+	Bytes []byte
+}
+
+// Scanner drives a set of NamedAcceptor rules over the runes decoded from
+// an io.Reader, emitting one Token per maximal run accepted by whichever
+// rule matches the first rune of that run.
+type Scanner struct {
+	r      *bufio.Reader
+	rules  []NamedAcceptor
+	policy InvalidUTF8Policy
+	pos    Position
+	saved  *decodedRune // rewound after a rule rejects
+}
+
+type decodedRune struct {
+	rn   rune
+	pos  Position
+	size int
+}
+
+// NewScanner returns a Scanner reading from r and matching tokens against
+// rules, tried in order.
+func NewScanner(r io.Reader, rules []NamedAcceptor) *Scanner {
+	return &Scanner{
+		r:     bufio.NewReader(r),
+		rules: rules,
+		pos:   Position{Line: 1, Col: 1},
+	}
+}
+
+// WithInvalidUTF8Policy sets how Scanner handles undecodable bytes and
+// returns s for chaining.
+func (s *Scanner) WithInvalidUTF8Policy(policy InvalidUTF8Policy) *Scanner {
+	s.policy = policy
+	return s
+}
+
+func (s *Scanner) advance(rn rune, size int) {
+	s.pos.Offset += size
+	if rn == '\n' {
+		s.pos.Line++
+		s.pos.Col = 1
+	} else {
+		s.pos.Col++
+	}
+}
+
+// readRune returns the next decoded rune, applying the InvalidUTF8Policy
+// to any undecodable bytes, or the error from the underlying reader.
+func (s *Scanner) readRune() (decodedRune, error) {
+	if s.saved != nil {
+		dr := *s.saved
+		s.saved = nil
+		return dr, nil
+	}
+	for {
+		rn, size, err := s.r.ReadRune()
+		if err != nil {
+			return decodedRune{}, err
+		}
+		if rn == utf8.RuneError && size == 1 {
+			switch s.policy {
+			case SkipInvalidUTF8:
+				s.advance(rn, size)
+				continue
+			case ErrorOnInvalidUTF8:
+				return decodedRune{}, fmt.Errorf("scanner: invalid UTF-8 at %d:%d", s.pos.Line, s.pos.Col)
+			}
+			// ReplaceInvalidUTF8 falls through with rn already U+FFFD.
+		}
+		pos := s.pos
+		s.advance(rn, size)
+		return decodedRune{rn: rn, pos: pos, size: size}, nil
+	}
+}
+
+// Next returns the next Token. It returns io.EOF once the stream is
+// exhausted with no partial token pending, and propagates any read or
+// invalid-UTF-8 error encountered along the way.
+func (s *Scanner) Next() (Token, error) {
+	for _, rule := range s.rules {
+		rule.Reset.Reset()
+	}
+
+	first, err := s.readRune()
+	if err != nil {
+		return Token{}, err
+	}
+
+	active := -1
+	for i, rule := range s.rules {
+		ok, err := rule.Acc(first.rn)
+		if err != nil {
+			return Token{}, err
+		}
+		if ok {
+			active = i
+			break
+		}
+	}
+	if active == -1 {
+		return Token{}, fmt.Errorf("scanner: no rule matched %q at %d:%d", first.rn, first.pos.Line, first.pos.Col)
+	}
+
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], first.rn)
+	bytes := append([]byte(nil), buf[:n]...)
+	end := s.pos
+
+	for {
+		dr, err := s.readRune()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return Token{}, err
+		}
+		ok, err := s.rules[active].Acc(dr.rn)
+		if err != nil {
+			return Token{}, err
+		}
+		if !ok {
+			s.saved = &dr
+			break
+		}
+		n := utf8.EncodeRune(buf[:], dr.rn)
+		bytes = append(bytes, buf[:n]...)
+		end = s.pos
+	}
+
+	s.rules[active].Reset.Reset()
+	return Token{Name: s.rules[active].Name, Start: first.pos, End: end, Bytes: bytes}, nil
+}